@@ -0,0 +1,385 @@
+// Package rediscluster is a Redis Cluster driver built on top of
+// redisconn.Connection: it keeps a slot -> node map seeded from CLUSTER
+// SLOTS, routes every request by CRC16 hash slot, and transparently follows
+// -MOVED/-ASK redirections.
+package rediscluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/redispipe/rediswrap"
+	"github.com/joomcode/redispipe/redisconn"
+)
+
+const (
+	numSlots = 16384
+	// maxRedirects bounds how many times a single request will follow
+	// -MOVED/-ASK before giving up and returning the last error seen.
+	maxRedirects = 3
+
+	defaultTopologyRefresh = 5 * time.Second
+)
+
+// Opts configures a Cluster.
+type Opts struct {
+	// ConnOpts are passed to every per-node redisconn.Connection.
+	ConnOpts redisconn.Opts
+	// TopologyRefresh is how often CLUSTER SLOTS is re-polled in the
+	// background, independent of redirections. If zero, 5 seconds is used.
+	TopologyRefresh time.Duration
+}
+
+type node struct {
+	addr string
+	conn *redisconn.Connection
+}
+
+// Cluster is a Redis Cluster client. It satisfies the same Send/SendBatch
+// contract as redisconn.Connection.
+type Cluster struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	seeds  []string
+	opts   Opts
+
+	mutex sync.RWMutex
+	slots [numSlots]*node
+	nodes map[string]*node
+}
+
+// Connect seeds a Cluster from the first reachable address in seeds and
+// starts a background topology refresher.
+func Connect(ctx context.Context, seeds []string, opts Opts) (*Cluster, error) {
+	if ctx == nil {
+		return nil, &redisconn.Error{Code: redisconn.ErrContextIsNil, Msg: "Context should not be nil"}
+	}
+	if opts.TopologyRefresh == 0 {
+		opts.TopologyRefresh = defaultTopologyRefresh
+	}
+	cl := &Cluster{seeds: seeds, opts: opts, nodes: map[string]*node{}}
+	cl.ctx, cl.cancel = context.WithCancel(ctx)
+
+	if err := cl.refreshTopology(); err != nil {
+		cl.cancel()
+		return nil, err
+	}
+	go cl.refresher()
+	return cl, nil
+}
+
+// Close closes every node connection and stops the topology refresher.
+func (cl *Cluster) Close() {
+	cl.cancel()
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	for _, n := range cl.nodes {
+		n.conn.Close()
+	}
+}
+
+// Send routes req by CRC16 hash slot of its first argument, following
+// -MOVED/-ASK redirections transparently.
+func (cl *Cluster) Send(req redisconn.Request, cb redisconn.Callback, n uint64) {
+	slot := redisconn.SlotByKey(firstArg(req))
+	cl.sendToSlot(slot, req, cb, n, 0)
+}
+
+// SendBatch splits requests whose keys land in different slots, dispatches
+// the pieces in parallel, and invokes cb for every request in the original
+// start+i order once the whole batch has completed. Requests that share a
+// slot are forwarded as a single sub-batch so they keep the benefit of the
+// underlying per-node pipelining.
+func (cl *Cluster) SendBatch(requests []redisconn.Request, cb redisconn.Callback, start uint64) {
+	if len(requests) == 0 {
+		return
+	}
+
+	bySlot := map[uint16][]int{}
+	for i, req := range requests {
+		slot := redisconn.SlotByKey(firstArg(req))
+		bySlot[slot] = append(bySlot[slot], i)
+	}
+
+	type result struct {
+		res interface{}
+		err error
+	}
+	results := make([]result, len(requests))
+	pending := int32(len(requests))
+
+	deliver := func(i int, res interface{}, err error) {
+		results[i] = result{res, err}
+		if atomic.AddInt32(&pending, -1) == 0 {
+			for j, r := range results {
+				cb(r.res, r.err, start+uint64(j))
+			}
+		}
+	}
+
+	for slot, idxs := range bySlot {
+		slot, idxs := slot, idxs // captured by the async callback below
+		nd := cl.nodeForSlot(slot)
+		if nd == nil {
+			for _, i := range idxs {
+				deliver(i, nil, &redisconn.Error{Code: redisconn.ErrDisconnected, Msg: "no node known for slot"})
+			}
+			continue
+		}
+		sub := make([]redisconn.Request, len(idxs))
+		for k, i := range idxs {
+			sub[k] = requests[i]
+		}
+		nd.conn.SendBatch(sub, func(res interface{}, err error, localN uint64) {
+			i := idxs[localN]
+			cl.handleReply(slot, requests[i], res, err, 0, func(res interface{}, err error, _ uint64) {
+				deliver(i, res, err)
+			}, start+uint64(i))
+		}, 0)
+	}
+}
+
+// SendToNode bypasses slot routing and sends req directly to addr, dialing
+// a connection to it if one isn't already open.
+func (cl *Cluster) SendToNode(addr string, req redisconn.Request, cb redisconn.Callback, n uint64) {
+	nd, err := cl.nodeFor(addr)
+	if err != nil {
+		cb(nil, err, n)
+		return
+	}
+	nd.conn.Send(req, cb, n)
+}
+
+func (cl *Cluster) sendToSlot(slot uint16, req redisconn.Request, cb redisconn.Callback, n uint64, redirects int) {
+	nd := cl.nodeForSlot(slot)
+	if nd == nil {
+		cb(nil, &redisconn.Error{Code: redisconn.ErrDisconnected, Msg: "no node known for slot"}, n)
+		return
+	}
+	nd.conn.Send(req, func(res interface{}, err error, _ uint64) {
+		cl.handleReply(slot, req, res, err, redirects, cb, n)
+	}, n)
+}
+
+// handleReply inspects the reply to a single request routed at slot. On
+// -ASK it forwards req once, with ASKING, to the node named in the reply,
+// without touching the slot map (an ASK redirect is a one-shot migration
+// hint, not a permanent ownership change). On -MOVED it rewrites cl.slots
+// for slot to point at the new owner before retrying, so every subsequent
+// request for that slot goes there directly instead of paying the extra
+// hop until the next topology refresh. Anything else is delivered to cb
+// unchanged.
+func (cl *Cluster) handleReply(slot uint16, req redisconn.Request, res interface{}, err error, redirects int, cb redisconn.Callback, n uint64) {
+	if err != nil && redirects < maxRedirects {
+		if addr, ask, ok := parseRedirect(err); ok {
+			target, derr := cl.nodeFor(addr)
+			if derr == nil {
+				if ask {
+					rediswrap.Sync{target.conn}.Send(redisconn.Request{Cmd: "ASKING"})
+					target.conn.Send(req, cb, n)
+					return
+				}
+				cl.mutex.Lock()
+				cl.slots[slot] = target
+				cl.mutex.Unlock()
+				cl.sendToSlot(slot, req, cb, n, redirects+1)
+				return
+			}
+			// Couldn't resolve the redirect target (eg a dial failure): a
+			// MOVED falls through to a full topology refresh below, an ASK
+			// error is delivered to cb as is.
+		}
+		if isMoved(err) {
+			go cl.refreshTopology()
+			cl.sendToSlot(slot, req, cb, n, redirects+1)
+			return
+		}
+	}
+	cb(res, err, n)
+}
+
+func (cl *Cluster) nodeForSlot(slot uint16) *node {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	return cl.slots[slot]
+}
+
+func (cl *Cluster) nodeFor(addr string) (*node, error) {
+	cl.mutex.RLock()
+	n := cl.nodes[addr]
+	cl.mutex.RUnlock()
+	if n != nil {
+		return n, nil
+	}
+
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	if n = cl.nodes[addr]; n != nil {
+		return n, nil
+	}
+	conn, err := redisconn.Connect(cl.ctx, addr, cl.opts.ConnOpts)
+	if err != nil {
+		return nil, err
+	}
+	n = &node{addr: addr, conn: conn}
+	cl.nodes[addr] = n
+	return n, nil
+}
+
+func (cl *Cluster) refresher() {
+	t := time.NewTicker(cl.opts.TopologyRefresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-cl.ctx.Done():
+			return
+		case <-t.C:
+			cl.refreshTopology()
+		}
+	}
+}
+
+// refreshTopology re-polls CLUSTER SLOTS, trying the original seeds first
+// and then every node already learned from a previous topology, so a
+// long-running Cluster keeps working after its seed nodes are decommissioned.
+func (cl *Cluster) refreshTopology() error {
+	addrs := append([]string{}, cl.seeds...)
+	cl.mutex.RLock()
+	for addr := range cl.nodes {
+		addrs = append(addrs, addr)
+	}
+	cl.mutex.RUnlock()
+
+	var lastErr error
+	tried := map[string]bool{}
+	for _, addr := range addrs {
+		if tried[addr] {
+			continue
+		}
+		tried[addr] = true
+		nd, err := cl.nodeFor(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res := rediswrap.Sync{nd.conn}.Send(redisconn.Request{Cmd: "CLUSTER", Args: []interface{}{"SLOTS"}})
+		if err := res.AnyError(); err != nil {
+			lastErr = err
+			continue
+		}
+		slots, ok := res.Value().([]interface{})
+		if !ok {
+			lastErr = &redisconn.Error{Code: redisconn.ErrResponse, Msg: "unexpected CLUSTER SLOTS reply"}
+			continue
+		}
+		cl.applySlots(slots)
+		return nil
+	}
+	return lastErr
+}
+
+func (cl *Cluster) applySlots(slots []interface{}) {
+	var newSlots [numSlots]*node
+	byAddr := map[string]*node{}
+	for _, s := range slots {
+		entry, ok := s.([]interface{})
+		if !ok || len(entry) < 3 {
+			continue
+		}
+		start, ok1 := toInt(entry[0])
+		end, ok2 := toInt(entry[1])
+		master, ok3 := entry[2].([]interface{})
+		if !ok1 || !ok2 || !ok3 || len(master) < 2 {
+			continue
+		}
+		host, _ := master[0].(string)
+		port, ok4 := toInt(master[1])
+		if host == "" || !ok4 {
+			continue
+		}
+		addr := host + ":" + strconv.Itoa(port)
+		n, ok := byAddr[addr]
+		if !ok {
+			var err error
+			n, err = cl.nodeFor(addr)
+			if err != nil {
+				continue
+			}
+			byAddr[addr] = n
+		}
+		for slot := start; slot <= end && slot < numSlots; slot++ {
+			newSlots[slot] = n
+		}
+	}
+	cl.mutex.Lock()
+	cl.slots = newSlots
+	// Drop (and close) nodes that no longer own any slot in the refreshed
+	// topology, so a long-running Cluster doesn't leak one connection per
+	// node that has ever been a master, eg across replica promotions.
+	var stale []*node
+	for addr, n := range cl.nodes {
+		if _, ok := byAddr[addr]; !ok {
+			stale = append(stale, n)
+			delete(cl.nodes, addr)
+		}
+	}
+	cl.mutex.Unlock()
+
+	for _, n := range stale {
+		n.conn.Close()
+	}
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return int(n), true
+	case int:
+		return n, true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func isMoved(err error) bool {
+	return strings.Contains(err.Error(), "MOVED ")
+}
+
+func parseRedirect(err error) (addr string, ask bool, ok bool) {
+	msg := err.Error()
+	prefix := "MOVED "
+	if strings.Contains(msg, "ASK ") && !strings.Contains(msg, "MOVED ") {
+		prefix, ask = "ASK ", true
+	}
+	idx := strings.Index(msg, prefix)
+	if idx < 0 {
+		return "", false, false
+	}
+	fields := strings.Fields(msg[idx+len(prefix):])
+	if len(fields) < 2 {
+		return "", false, false
+	}
+	return fields[1], ask, true
+}
+
+func firstArg(req redisconn.Request) []byte {
+	if len(req.Args) == 0 {
+		return nil
+	}
+	switch k := req.Args[0].(type) {
+	case string:
+		return []byte(k)
+	case []byte:
+		return k
+	default:
+		return []byte(fmt.Sprintf("%v", k))
+	}
+}
@@ -0,0 +1,38 @@
+package rediscluster
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRedirect(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		wantAddr string
+		wantAsk  bool
+		wantOK   bool
+	}{
+		{"moved", errors.New("MOVED 3999 127.0.0.1:7001"), "127.0.0.1:7001", false, true},
+		{"ask", errors.New("ASK 3999 127.0.0.1:7002"), "127.0.0.1:7002", true, true},
+		{"unrelated error", errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), "", false, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addr, ask, ok := parseRedirect(c.err)
+			if addr != c.wantAddr || ask != c.wantAsk || ok != c.wantOK {
+				t.Errorf("parseRedirect(%q) = (%q, %v, %v), want (%q, %v, %v)",
+					c.err, addr, ask, ok, c.wantAddr, c.wantAsk, c.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsMoved(t *testing.T) {
+	if !isMoved(errors.New("MOVED 3999 127.0.0.1:7001")) {
+		t.Fatal("expected a MOVED error to be detected")
+	}
+	if isMoved(errors.New("ASK 3999 127.0.0.1:7002")) {
+		t.Fatal("an ASK error must not be treated as MOVED")
+	}
+}
@@ -3,9 +3,12 @@ package redisconn
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/url"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -39,6 +42,14 @@ type Opts struct {
 	DB int
 	// Password for AUTH
 	Password string
+	// Username for Redis 6 ACL AUTH. If set together with a password, AUTH
+	// is issued with both username and password (`AUTH username password`);
+	// without a password no AUTH is sent.
+	Username string
+	// TLSConfig, if not nil, is used to establish a TLS connection to the
+	// server. It is also implied (with a zero-value *tls.Config) by a
+	// rediss:// scheme in addr.
+	TLSConfig *tls.Config
 	// Handle is returned with Connection.Handle()
 	Handle interface{}
 	// Concurrency - number for shards. Default is runtime.GOMAXPROCS(-1)*4
@@ -53,6 +64,30 @@ type Opts struct {
 	Logger Logger
 	// Async - do not establish connection immediately
 	Async bool
+	// Hooks, if set, are notified around every request/pipeline sent
+	// through SendCtx/SendBatchCtx and around every dial attempt, letting
+	// callers wire in tracing/metrics without patching this package.
+	Hooks Hooks
+}
+
+// Hooks lets callers observe requests going through a Connection, similar to
+// the observability surface go-redis v8 exposes. Implementations must be
+// safe for concurrent use; methods are called from arbitrary goroutines.
+type Hooks interface {
+	// BeforeProcess is called before req is sent, and may return a
+	// replacement context carried through to AfterProcess.
+	BeforeProcess(ctx context.Context, req Request) context.Context
+	// AfterProcess is called once req's reply (or error) is known.
+	AfterProcess(ctx context.Context, req Request, res interface{}, err error)
+	// BeforeProcessPipeline is called before a batch is sent, and may
+	// return a replacement context carried through to AfterProcessPipeline.
+	BeforeProcessPipeline(ctx context.Context, requests []Request) context.Context
+	// AfterProcessPipeline is called once every reply in the batch is known.
+	// errs has one entry per request, in request order, nil where there was
+	// no error.
+	AfterProcessPipeline(ctx context.Context, requests []Request, errs []error)
+	// DialHook is called after every dial attempt, err is nil on success.
+	DialHook(ctx context.Context, addr string, err error)
 }
 
 type Connection struct {
@@ -220,6 +255,50 @@ func (conn *Connection) getShard() (uint32, *connShard) {
 	return shardn, &conn.shard[shardn]
 }
 
+// SendCtx is Send with a context.Context threaded through to Opts.Hooks, for
+// callers that wired in tracing/metrics hooks. With no Hooks configured it
+// behaves exactly like Send.
+func (conn *Connection) SendCtx(ctx context.Context, req Request, cb Callback, n uint64) {
+	if cb == nil {
+		cb = func(interface{}, error, uint64) {}
+	}
+	if conn.opts.Hooks != nil {
+		hooks := conn.opts.Hooks
+		ctx = hooks.BeforeProcess(ctx, req)
+		orig := cb
+		cb = func(res interface{}, err error, n uint64) {
+			hooks.AfterProcess(ctx, req, res, err)
+			orig(res, err, n)
+		}
+	}
+	conn.Send(req, cb, n)
+}
+
+// SendBatchCtx is SendBatch with a context.Context threaded through to
+// Opts.Hooks. With no Hooks configured it behaves exactly like SendBatch.
+func (conn *Connection) SendBatchCtx(ctx context.Context, requests []Request, cb Callback, start uint64) {
+	if cb == nil {
+		cb = func(interface{}, error, uint64) {}
+	}
+	if conn.opts.Hooks != nil && len(requests) > 0 {
+		hooks := conn.opts.Hooks
+		ctx = hooks.BeforeProcessPipeline(ctx, requests)
+		errs := make([]error, len(requests))
+		pending := int32(len(requests))
+		orig := cb
+		cb = func(res interface{}, err error, n uint64) {
+			if idx := n - start; idx < uint64(len(errs)) {
+				errs[idx] = err
+			}
+			orig(res, err, n)
+			if atomic.AddInt32(&pending, -1) == 0 {
+				hooks.AfterProcessPipeline(ctx, requests, errs)
+			}
+		}
+	}
+	conn.SendBatch(requests, cb, start)
+}
+
 func (conn *Connection) Send(req Request, cb Callback, n uint64) {
 	shardn, shard := conn.getShard()
 	if cb == nil {
@@ -325,26 +404,97 @@ func (conn *Connection) unlockShards() {
 	}
 }
 
-func (conn *Connection) dial() error {
+// parseAddr interprets addr either as a bare "host:port" / unix socket path,
+// or as a redis://, rediss:// or unix:// URI as accepted by mainstream Redis
+// clients (go-redis, redigo). It returns the network and address to pass to
+// net.Dialer, whether TLS is required, and any auth/DB embedded in the URI.
+// parseAddr also reports dbSet, since a URI that doesn't mention a DB at all
+// must be distinguishable from one that explicitly selects DB 0: the former
+// should fall back to Opts.DB, the latter must not.
+func parseAddr(addr string) (network, address string, tlsEnabled bool, username, password string, db int, dbSet bool, err error) {
+	network, address = "tcp", addr
+	switch {
+	case addr == "":
+		return
+	case addr[0] == '.' || addr[0] == '/':
+		network = "unix"
+		return
+	case strings.HasPrefix(addr, "unix://"):
+		network = "unix"
+		u, uerr := url.Parse(addr)
+		if uerr != nil {
+			return "", "", false, "", "", 0, false, uerr
+		}
+		address = u.Path
+		username, password = userinfo(u)
+		db, dbSet, err = dbFromQuery(u)
+		return
+	case strings.HasPrefix(addr, "tcp://"):
+		address = addr[len("tcp://"):]
+		return
+	case strings.HasPrefix(addr, "redis://"), strings.HasPrefix(addr, "rediss://"):
+		u, uerr := url.Parse(addr)
+		if uerr != nil {
+			return "", "", false, "", "", 0, false, uerr
+		}
+		tlsEnabled = u.Scheme == "rediss"
+		address = u.Host
+		username, password = userinfo(u)
+		if p := strings.Trim(u.Path, "/"); p != "" {
+			if db, err = strconv.Atoi(p); err != nil {
+				return
+			}
+			dbSet = true
+		}
+		if !dbSet {
+			db, dbSet, err = dbFromQuery(u)
+		}
+		return
+	}
+	return
+}
+
+func userinfo(u *url.URL) (username, password string) {
+	if u.User == nil {
+		return "", ""
+	}
+	password, _ = u.User.Password()
+	return u.User.Username(), password
+}
+
+func dbFromQuery(u *url.URL) (db int, dbSet bool, err error) {
+	q := u.Query().Get("db")
+	if q == "" {
+		return 0, false, nil
+	}
+	db, err = strconv.Atoi(q)
+	return db, err == nil, err
+}
+
+func (conn *Connection) dial() (err error) {
+	if conn.opts.Hooks != nil {
+		defer func() { conn.opts.Hooks.DialHook(conn.ctx, conn.addr, err) }()
+	}
 	var connection net.Conn
-	var err error
-	network := "tcp"
-	address := conn.addr
+	network, address, tlsEnabled, username, password, db, dbSet, err := parseAddr(conn.addr)
+	if err != nil {
+		return &Error{Conn: conn, Code: ErrDial, Wrap: err}
+	}
+	if password == "" {
+		password = conn.opts.Password
+	}
+	if username == "" {
+		username = conn.opts.Username
+	}
+	if !dbSet {
+		db = conn.opts.DB
+	}
 	timeout := conn.opts.ReconnectPause / 2
 	if timeout <= 0 {
 		timeout = defaultReconnectPause / 2
 	} else if timeout > 5*time.Second {
 		timeout = 5 * time.Second
 	}
-	if address[0] == '.' || address[0] == '/' {
-		network = "unix"
-	} else if address[0:7] == "unix://" {
-		network = "unix"
-		address = address[7:]
-	} else if address[0:6] == "tcp://" {
-		network = "tcp"
-		address = address[6:]
-	}
 	dialer := net.Dialer{
 		Timeout:       timeout,
 		DualStack:     true,
@@ -355,17 +505,41 @@ func (conn *Connection) dial() error {
 	if err != nil {
 		return &Error{Conn: conn, Code: ErrDial, Wrap: err}
 	}
+	if tlsEnabled || conn.opts.TLSConfig != nil {
+		tlsConfig := conn.opts.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			if host, _, splitErr := net.SplitHostPort(address); splitErr == nil {
+				tlsConfig.ServerName = host
+			} else {
+				tlsConfig.ServerName = address
+			}
+		}
+		tlsConn := tls.Client(connection, tlsConfig)
+		if err = tlsConn.HandshakeContext(conn.ctx); err != nil {
+			connection.Close()
+			return &Error{Conn: conn, Code: ErrDial, Wrap: err}
+		}
+		connection = tlsConn
+	}
 	dc := newDeadlineIO(connection, conn.opts.IOTimeout)
 	r := bufio.NewReaderSize(dc, 128*1024)
 	w := bufio.NewWriterSize(dc, 128*1024)
 
 	var req []byte
-	if conn.opts.Password != "" {
-		req, _ = resp.AppendRequest(req, "AUTH", []interface{}{conn.opts.Password})
+	if password != "" {
+		if username != "" {
+			req, _ = resp.AppendRequest(req, "AUTH", []interface{}{username, password})
+		} else {
+			req, _ = resp.AppendRequest(req, "AUTH", []interface{}{password})
+		}
 	}
 	req, _ = resp.AppendRequest(req, "PING", nil)
-	if conn.opts.DB != 0 {
-		req, _ = resp.AppendRequest(req, "SELECT", []interface{}{conn.opts.DB})
+	if db != 0 {
+		req, _ = resp.AppendRequest(req, "SELECT", []interface{}{db})
 	}
 	if _, err = dc.Write(req); err != nil {
 		connection.Close()
@@ -373,7 +547,7 @@ func (conn *Connection) dial() error {
 	}
 	var res interface{}
 	// Password response
-	if conn.opts.Password != "" {
+	if password != "" {
 		if res, err = resp.Read(r); err != nil {
 			connection.Close()
 			return err
@@ -396,7 +570,7 @@ func (conn *Connection) dial() error {
 		return &Error{Conn: conn, Code: ErrPing, Msg: fmt.Sprintf("Ping response mismatch: %#v", res)}
 	}
 	// SELECT DB Response
-	if conn.opts.DB != 0 {
+	if db != 0 {
 		if res, err = resp.Read(r); err != nil {
 			connection.Close()
 			return err
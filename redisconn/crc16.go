@@ -0,0 +1,33 @@
+package redisconn
+
+import "bytes"
+
+// SlotByKey returns the Redis Cluster hash slot (0..16383) for key, honoring
+// "{...}" hash tags the same way Redis itself does: if key contains a
+// non-empty "{tag}", only the bytes inside the braces are hashed, so that
+// keys sharing a hash tag always land in the same slot.
+func SlotByKey(key []byte) uint16 {
+	if start := bytes.IndexByte(key, '{'); start >= 0 {
+		if end := bytes.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % 16384
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) variant Redis Cluster uses for
+// key hashing: polynomial 0x1021, no input/output reflection, zero init.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
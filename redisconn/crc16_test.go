@@ -0,0 +1,33 @@
+package redisconn
+
+import "testing"
+
+func TestSlotByKey(t *testing.T) {
+	// "123456789" is the standard CRC16-CCITT/XMODEM check value; Redis
+	// Cluster's own spec uses HASH_SLOT = CRC16("123456789") mod 16384 = 12739
+	// as its worked example.
+	cases := []struct {
+		key  string
+		slot uint16
+	}{
+		{"123456789", 12739},
+	}
+	for _, c := range cases {
+		if got := SlotByKey([]byte(c.key)); got != c.slot {
+			t.Errorf("SlotByKey(%q) = %d, want %d", c.key, got, c.slot)
+		}
+	}
+}
+
+func TestSlotByKeyHashTag(t *testing.T) {
+	// Classic Redis Cluster hash-tag example: keys sharing "{user1000}" must
+	// land in the same slot as the bare tag contents.
+	a := SlotByKey([]byte("{user1000}.following"))
+	b := SlotByKey([]byte("{user1000}.followers"))
+	if a != b {
+		t.Fatalf("keys sharing a hash tag landed in different slots: %d != %d", a, b)
+	}
+	if got := SlotByKey([]byte("user1000")); got != a {
+		t.Errorf("SlotByKey(%q) = %d, want %d (same as the {user1000} tag contents)", "user1000", got, a)
+	}
+}
@@ -0,0 +1,141 @@
+package redisconn
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// HashStrategy picks which member connection of a Pool a request is routed
+// to.
+type HashStrategy int
+
+const (
+	// HashRendezvous routes by rendezvous (HRW) hashing on the first
+	// argument of the request, so a key keeps affinity to the same
+	// connection even as connections are added or removed.
+	HashRendezvous HashStrategy = iota
+	// HashSlot routes by the CRC16 hash-slot Redis Cluster itself uses
+	// (see SlotByKey), so keys sharing a {hashtag} always land on the
+	// same connection.
+	HashSlot
+	// HashRoundRobin ignores the request and cycles through connections,
+	// maximising throughput for callers that don't need per-key affinity.
+	HashRoundRobin
+)
+
+// PoolOpts configures a Pool.
+type PoolOpts struct {
+	// Opts are passed to every member Connection.
+	Opts Opts
+	// Size is the number of parallel connections opened to addr.
+	// If Size == 0, then 4 is used.
+	Size uint32
+	// Strategy picks which member connection a request is routed to.
+	// Default is HashRendezvous.
+	Strategy HashStrategy
+}
+
+// Pool is a set of parallel Connections to the same Redis server. It exposes
+// the same Send/SendBatch surface as a single Connection, spreading requests
+// across its member connections so that no single reader goroutine becomes
+// the throughput bottleneck.
+type Pool struct {
+	opts    PoolOpts
+	conns   []*Connection
+	rrCount uint32
+}
+
+// NewPool dials opts.Size connections to addr and returns a Pool.
+// If any dial fails, the connections already opened are closed and the
+// error is returned.
+func NewPool(ctx context.Context, addr string, opts PoolOpts) (*Pool, error) {
+	if opts.Size == 0 {
+		opts.Size = 4
+	}
+	pool := &Pool{opts: opts, conns: make([]*Connection, opts.Size)}
+	for i := range pool.conns {
+		conn, err := Connect(ctx, addr, opts.Opts)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.conns[i] = conn
+	}
+	return pool, nil
+}
+
+// Send implements the same contract as Connection.Send, routing req to one
+// member connection chosen by opts.Strategy.
+func (p *Pool) Send(req Request, cb Callback, n uint64) {
+	p.pick(req).Send(req, cb, n)
+}
+
+// SendBatch implements the same contract as Connection.SendBatch. All
+// requests in the batch are routed together, keyed by the first request.
+func (p *Pool) SendBatch(requests []Request, cb Callback, start uint64) {
+	if len(requests) == 0 {
+		return
+	}
+	p.pick(requests[0]).SendBatch(requests, cb, start)
+}
+
+// Close closes every member connection.
+func (p *Pool) Close() {
+	for _, conn := range p.conns {
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+// ConnForKey exposes the routing decision directly, which is useful for
+// callers that need several requests (eg MULTI/EXEC) to land on the same
+// connection as req would.
+func (p *Pool) ConnForKey(req Request) *Connection {
+	return p.pick(req)
+}
+
+func (p *Pool) pick(req Request) *Connection {
+	switch p.opts.Strategy {
+	case HashSlot:
+		return p.conns[SlotByKey(firstArg(req))%uint16(len(p.conns))]
+	case HashRoundRobin:
+		n := atomic.AddUint32(&p.rrCount, 1)
+		return p.conns[n%uint32(len(p.conns))]
+	default:
+		return p.conns[p.rendezvous(firstArg(req))]
+	}
+}
+
+func (p *Pool) rendezvous(key []byte) int {
+	best, bestScore := 0, uint32(0)
+	for i := range p.conns {
+		if score := rendezvousScore(key, i); score >= bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+func rendezvousScore(key []byte, node int) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	h.Write([]byte{byte(node), byte(node >> 8), byte(node >> 16), byte(node >> 24)})
+	return h.Sum32()
+}
+
+func firstArg(req Request) []byte {
+	if len(req.Args) == 0 {
+		return nil
+	}
+	switch k := req.Args[0].(type) {
+	case string:
+		return []byte(k)
+	case []byte:
+		return k
+	default:
+		return []byte(fmt.Sprintf("%v", k))
+	}
+}
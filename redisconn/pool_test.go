@@ -0,0 +1,44 @@
+package redisconn
+
+import "testing"
+
+func dummyConns(n int) []*Connection {
+	conns := make([]*Connection, n)
+	for i := range conns {
+		conns[i] = &Connection{}
+	}
+	return conns
+}
+
+func TestPoolPickRendezvousIsStableForAKey(t *testing.T) {
+	p := &Pool{opts: PoolOpts{Strategy: HashRendezvous}, conns: dummyConns(4)}
+	req := Request{Cmd: "GET", Args: []interface{}{"some-key"}}
+
+	want := p.pick(req)
+	for i := 0; i < 10; i++ {
+		if got := p.pick(req); got != want {
+			t.Fatalf("HashRendezvous picked a different connection for the same key on call %d", i)
+		}
+	}
+}
+
+func TestPoolPickHashSlotGroupsHashTags(t *testing.T) {
+	p := &Pool{opts: PoolOpts{Strategy: HashSlot}, conns: dummyConns(4)}
+	a := p.pick(Request{Cmd: "GET", Args: []interface{}{"{tag}.a"}})
+	b := p.pick(Request{Cmd: "GET", Args: []interface{}{"{tag}.b"}})
+	if a != b {
+		t.Fatalf("HashSlot routed keys sharing a hash tag to different connections")
+	}
+}
+
+func TestPoolPickRoundRobinCycles(t *testing.T) {
+	p := &Pool{opts: PoolOpts{Strategy: HashRoundRobin}, conns: dummyConns(3)}
+	req := Request{Cmd: "GET", Args: []interface{}{"key"}}
+	seen := map[*Connection]bool{}
+	for i := 0; i < len(p.conns); i++ {
+		seen[p.pick(req)] = true
+	}
+	if len(seen) != len(p.conns) {
+		t.Fatalf("HashRoundRobin visited %d distinct connections in %d picks, want %d", len(seen), len(p.conns), len(p.conns))
+	}
+}
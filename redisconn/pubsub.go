@@ -0,0 +1,357 @@
+package redisconn
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/redispipe/resp"
+)
+
+// Message is a single Pub/Sub notification delivered to a PubSub's callback.
+type Message struct {
+	// Channel is the channel the message arrived on.
+	Channel string
+	// Pattern is the PSUBSCRIBE pattern that matched, or "" for a plain
+	// channel subscription.
+	Pattern string
+	// Payload is the message body.
+	Payload string
+}
+
+// PubSubOpts configures a PubSub connection. A zero ReconnectPause and a nil
+// Logger fall back to the same defaults as Opts. A zero DialTimeout means
+// defaultReconnectPause, and a zero TCPKeepAlive is passed to net.Dialer
+// as is, so net.Dialer's own keep-alive default applies.
+type PubSubOpts struct {
+	Password       string
+	Username       string
+	TLSConfig      *tls.Config
+	DialTimeout    time.Duration
+	TCPKeepAlive   time.Duration
+	ReconnectPause time.Duration
+	Logger         Logger
+}
+
+// PubSub is a dedicated Pub/Sub connection: it owns its own socket (bypassing
+// the sharded pipelining writer, which assumes strict one-request/one-reply
+// ordering and is incompatible with server-pushed messages), remembers every
+// channel/pattern it has been asked to subscribe to, and replays those
+// subscriptions after every reconnect so callers never have to notice a drop.
+type PubSub struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	addr      string
+	opts      PubSubOpts
+	onMessage func(Message)
+
+	mutex    sync.Mutex
+	c        net.Conn
+	w        *bufio.Writer
+	state    uint32
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	pong chan struct{}
+}
+
+// NewPubSub dials addr and returns a ready PubSub that calls onMessage for
+// every message received on channels subscribed to via Subscribe/PSubscribe.
+// onMessage is called from an internal goroutine and must not block for long.
+func NewPubSub(ctx context.Context, addr string, onMessage func(Message), opts PubSubOpts) (*PubSub, error) {
+	if ctx == nil {
+		return nil, &Error{Code: ErrContextIsNil, Msg: "Context should not be nil"}
+	}
+	if opts.ReconnectPause == 0 {
+		opts.ReconnectPause = defaultReconnectPause
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger{}
+	}
+	ps := &PubSub{
+		addr:      addr,
+		opts:      opts,
+		onMessage: onMessage,
+		channels:  map[string]struct{}{},
+		patterns:  map[string]struct{}{},
+	}
+	ps.ctx, ps.cancel = context.WithCancel(ctx)
+
+	if err := ps.connect(); err != nil {
+		return nil, err
+	}
+	go ps.control()
+	return ps, nil
+}
+
+// Subscribe adds channels to the set this PubSub listens on, remembering
+// them so a future reconnect replays the SUBSCRIBE.
+func (ps *PubSub) Subscribe(channels ...string) error {
+	return ps.subscribe("SUBSCRIBE", ps.channels, channels)
+}
+
+// Unsubscribe removes channels from the subscribed set.
+func (ps *PubSub) Unsubscribe(channels ...string) error {
+	return ps.unsubscribe("UNSUBSCRIBE", ps.channels, channels)
+}
+
+// PSubscribe adds patterns to the set this PubSub listens on.
+func (ps *PubSub) PSubscribe(patterns ...string) error {
+	return ps.subscribe("PSUBSCRIBE", ps.patterns, patterns)
+}
+
+// PUnsubscribe removes patterns from the subscribed set.
+func (ps *PubSub) PUnsubscribe(patterns ...string) error {
+	return ps.unsubscribe("PUNSUBSCRIBE", ps.patterns, patterns)
+}
+
+// Ping checks liveness of the underlying socket.
+func (ps *PubSub) Ping() error {
+	ps.mutex.Lock()
+	pong := make(chan struct{})
+	ps.pong = pong
+	err := ps.writeCommand("PING", nil)
+	ps.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-pong:
+		return nil
+	case <-time.After(ps.ioTimeout()):
+		return &Error{Code: ErrPing, Msg: "no pong received"}
+	case <-ps.ctx.Done():
+		return &Error{Code: ErrContextClosed, Wrap: ps.ctx.Err()}
+	}
+}
+
+// Close closes the PubSub forever.
+func (ps *PubSub) Close() {
+	ps.cancel()
+}
+
+func (ps *PubSub) ioTimeout() time.Duration {
+	if ps.opts.ReconnectPause <= 0 {
+		return defaultIOTimeout
+	}
+	return ps.opts.ReconnectPause
+}
+
+func (ps *PubSub) subscribe(cmd string, set map[string]struct{}, names []string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	err := ps.writeCommand(cmd, names)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return nil
+}
+
+func (ps *PubSub) unsubscribe(cmd string, set map[string]struct{}, names []string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	err := ps.writeCommand(cmd, names)
+	for _, name := range names {
+		delete(set, name)
+	}
+	return err
+}
+
+func (ps *PubSub) writeCommand(cmd string, names []string) error {
+	if atomic.LoadUint32(&ps.state) != connConnected {
+		return &Error{Code: ErrDisconnected, Msg: "pubsub connection is broken at the moment"}
+	}
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	buf, err := resp.AppendRequest(nil, cmd, args)
+	if err != nil {
+		return &Error{Code: ErrArgumentType, Wrap: err}
+	}
+	if _, err = ps.w.Write(buf); err != nil {
+		return &Error{Code: ErrIO, Wrap: err}
+	}
+	return ps.w.Flush()
+}
+
+func (ps *PubSub) connect() error {
+	network, address, tlsEnabled, username, password, _, _, err := parseAddr(ps.addr)
+	if err != nil {
+		return &Error{Code: ErrDial, Wrap: err}
+	}
+	if password == "" {
+		password = ps.opts.Password
+	}
+	if username == "" {
+		username = ps.opts.Username
+	}
+	timeout := ps.opts.DialTimeout
+	if timeout <= 0 {
+		timeout = defaultReconnectPause
+	}
+	dialer := net.Dialer{Timeout: timeout, KeepAlive: ps.opts.TCPKeepAlive}
+	connection, err := dialer.DialContext(ps.ctx, network, address)
+	if err != nil {
+		return &Error{Code: ErrDial, Wrap: err}
+	}
+	if tlsEnabled || ps.opts.TLSConfig != nil {
+		tlsConfig := ps.opts.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			if host, _, splitErr := net.SplitHostPort(address); splitErr == nil {
+				tlsConfig.ServerName = host
+			} else {
+				tlsConfig.ServerName = address
+			}
+		}
+		connection = tls.Client(connection, tlsConfig)
+	}
+	dc := newDeadlineIO(connection, 0)
+	r := bufio.NewReaderSize(dc, 16*1024)
+	w := bufio.NewWriterSize(dc, 16*1024)
+
+	if password != "" {
+		var req []byte
+		if username != "" {
+			req, _ = resp.AppendRequest(req, "AUTH", []interface{}{username, password})
+		} else {
+			req, _ = resp.AppendRequest(req, "AUTH", []interface{}{password})
+		}
+		if _, err = dc.Write(req); err != nil {
+			connection.Close()
+			return &Error{Code: ErrIO, Wrap: err}
+		}
+		if res, rerr := resp.Read(r); rerr != nil {
+			connection.Close()
+			return &Error{Code: ErrIO, Wrap: rerr}
+		} else if e, ok := res.(error); ok {
+			connection.Close()
+			return &Error{Code: ErrAuth, Msg: e.Error()}
+		}
+	}
+
+	ps.mutex.Lock()
+	ps.c, ps.w = connection, w
+	names := make([]string, 0, len(ps.channels))
+	for name := range ps.channels {
+		names = append(names, name)
+	}
+	patterns := make([]string, 0, len(ps.patterns))
+	for pattern := range ps.patterns {
+		patterns = append(patterns, pattern)
+	}
+	atomic.StoreUint32(&ps.state, connConnected)
+	// Replay the remembered subscriptions while still holding ps.mutex: it
+	// guards ps.w, and writeCommand does no locking of its own, so this must
+	// not race with a concurrent Subscribe/Unsubscribe/Ping call.
+	if len(names) != 0 {
+		ps.writeCommand("SUBSCRIBE", names)
+	}
+	if len(patterns) != 0 {
+		ps.writeCommand("PSUBSCRIBE", patterns)
+	}
+	ps.mutex.Unlock()
+
+	go ps.reader(r)
+	return nil
+}
+
+func (ps *PubSub) reader(r *bufio.Reader) {
+	for {
+		res, err := resp.Read(r)
+		if err != nil {
+			ps.reconnect(err)
+			return
+		}
+		reply, ok := res.([]interface{})
+		if s, isStr := res.(string); isStr && s == "PONG" {
+			// With no active subscription, PING is answered with a plain +PONG.
+			reply, ok = []interface{}{"pong"}, true
+		}
+		if !ok || len(reply) == 0 {
+			continue
+		}
+		kind, _ := reply[0].(string)
+		switch kind {
+		case "message":
+			if len(reply) == 3 {
+				channel, _ := reply[1].(string)
+				payload, _ := reply[2].(string)
+				ps.onMessage(Message{Channel: channel, Payload: payload})
+			}
+		case "pmessage":
+			if len(reply) == 4 {
+				pattern, _ := reply[1].(string)
+				channel, _ := reply[2].(string)
+				payload, _ := reply[3].(string)
+				ps.onMessage(Message{Channel: channel, Pattern: pattern, Payload: payload})
+			}
+		case "pong":
+			ps.mutex.Lock()
+			pong := ps.pong
+			ps.pong = nil
+			ps.mutex.Unlock()
+			if pong != nil {
+				close(pong)
+			}
+		}
+	}
+}
+
+func (ps *PubSub) report(event LogKind, v ...interface{}) {
+	ps.opts.Logger.Report(event, ps, v...)
+}
+
+func (ps *PubSub) reconnect(neterr error) {
+	ps.mutex.Lock()
+	atomic.StoreUint32(&ps.state, connDisconnected)
+	if ps.c != nil {
+		ps.c.Close()
+		ps.c = nil
+	}
+	ps.report(LogDisconnected, neterr)
+	ps.mutex.Unlock()
+}
+
+func (ps *PubSub) control() {
+	pause := ps.opts.ReconnectPause
+	if pause <= 0 {
+		pause = defaultReconnectPause
+	}
+	t := time.NewTicker(pause)
+	defer t.Stop()
+	for {
+		select {
+		case <-ps.ctx.Done():
+			ps.mutex.Lock()
+			atomic.StoreUint32(&ps.state, connClosed)
+			if ps.c != nil {
+				ps.c.Close()
+				ps.c = nil
+			}
+			ps.mutex.Unlock()
+			return
+		case <-t.C:
+		}
+		if atomic.LoadUint32(&ps.state) == connDisconnected {
+			if err := ps.connect(); err != nil {
+				ps.report(LogConnectFailed, err)
+			}
+			continue
+		}
+		if err := ps.Ping(); err != nil {
+			ps.reconnect(err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package redisconn
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sender is anything that can send a single Request asynchronously and
+// deliver exactly one reply to cb. *Connection and *Pool both satisfy it.
+type Sender interface {
+	Send(req Request, cb Callback, n uint64)
+}
+
+// ScanOpts configures an Iterator.
+type ScanOpts struct {
+	// Match restricts results to a glob-style pattern.
+	Match string
+	// Count is a hint to Redis about how many elements to scan per call;
+	// it does not bound how many elements a single Next call returns.
+	Count int
+	// Type restricts the top-level SCAN to keys of a single type (eg
+	// "string", "hash"). Ignored by HScan/SScan/ZScan.
+	Type string
+}
+
+// Iterator incrementally walks a SCAN-family cursor. Because the cursor
+// returned by one call must be fed into the next, at most one request is
+// ever in flight, so Next blocks until its batch arrives or ctx is done.
+type Iterator struct {
+	ctx    context.Context
+	sender Sender
+	cmd    string
+	key    string // empty for the top-level SCAN
+	opts   ScanOpts
+
+	cursor string
+	done   bool
+	err    error
+}
+
+// Scan starts a keyspace iterator (the SCAN command).
+func (conn *Connection) Scan(ctx context.Context, opts ScanOpts) *Iterator {
+	return newIterator(ctx, conn, "SCAN", "", opts)
+}
+
+// HScan starts a hash field iterator (HSCAN key).
+func (conn *Connection) HScan(ctx context.Context, key string, opts ScanOpts) *Iterator {
+	return newIterator(ctx, conn, "HSCAN", key, opts)
+}
+
+// SScan starts a set member iterator (SSCAN key).
+func (conn *Connection) SScan(ctx context.Context, key string, opts ScanOpts) *Iterator {
+	return newIterator(ctx, conn, "SSCAN", key, opts)
+}
+
+// ZScan starts a sorted-set member iterator (ZSCAN key).
+func (conn *Connection) ZScan(ctx context.Context, key string, opts ScanOpts) *Iterator {
+	return newIterator(ctx, conn, "ZSCAN", key, opts)
+}
+
+func newIterator(ctx context.Context, sender Sender, cmd, key string, opts ScanOpts) *Iterator {
+	return &Iterator{ctx: ctx, sender: sender, cmd: cmd, key: key, opts: opts, cursor: "0"}
+}
+
+// Next blocks until the next batch of elements arrives, or returns false
+// once the cursor is exhausted, ctx is done, or an error occurred (use Err
+// to distinguish the latter two from plain exhaustion).
+func (it *Iterator) Next() ([]string, bool) {
+	if it.done || it.err != nil {
+		return nil, false
+	}
+
+	args := []interface{}{it.cursor}
+	if it.key != "" {
+		args = []interface{}{it.key, it.cursor}
+	}
+	if it.opts.Match != "" {
+		args = append(args, "MATCH", it.opts.Match)
+	}
+	if it.opts.Count != 0 {
+		args = append(args, "COUNT", it.opts.Count)
+	}
+	if it.cmd == "SCAN" && it.opts.Type != "" {
+		args = append(args, "TYPE", it.opts.Type)
+	}
+
+	type reply struct {
+		res interface{}
+		err error
+	}
+	ch := make(chan reply, 1)
+	it.sender.Send(Request{Cmd: it.cmd, Args: args}, func(res interface{}, err error, _ uint64) {
+		ch <- reply{res, err}
+	}, 0)
+
+	var r reply
+	select {
+	case r = <-ch:
+	case <-it.ctx.Done():
+		it.err = &Error{Code: ErrContextClosed, Wrap: it.ctx.Err()}
+		return nil, false
+	}
+	if r.err != nil {
+		it.err = r.err
+		return nil, false
+	}
+
+	parts, ok := r.res.([]interface{})
+	if !ok || len(parts) != 2 {
+		it.err = &Error{Code: ErrResponse, Msg: fmt.Sprintf("unexpected %s reply: %#v", it.cmd, r.res)}
+		return nil, false
+	}
+	cursor, ok := parts[0].(string)
+	if !ok {
+		it.err = &Error{Code: ErrResponse, Msg: fmt.Sprintf("unexpected %s cursor type: %#v", it.cmd, parts[0])}
+		return nil, false
+	}
+	items, ok := parts[1].([]interface{})
+	if !ok {
+		it.err = &Error{Code: ErrResponse, Msg: fmt.Sprintf("unexpected %s element list type: %#v", it.cmd, parts[1])}
+		return nil, false
+	}
+
+	it.cursor = cursor
+	if it.cursor == "0" {
+		it.done = true
+	}
+
+	elems := make([]string, 0, len(items))
+	for _, v := range items {
+		if s, ok := v.(string); ok {
+			elems = append(elems, s)
+		}
+	}
+	return elems, true
+}
+
+// Err returns the error that stopped iteration, if Next returned false
+// because of an error rather than plain cursor exhaustion.
+func (it *Iterator) Err() error {
+	return it.err
+}
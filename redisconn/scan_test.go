@@ -0,0 +1,77 @@
+package redisconn
+
+import (
+	"context"
+	"testing"
+)
+
+type sendFunc func(req Request, cb Callback, n uint64)
+
+func (f sendFunc) Send(req Request, cb Callback, n uint64) { f(req, cb, n) }
+
+func TestIteratorWalksUntilCursorZero(t *testing.T) {
+	replies := []interface{}{
+		[]interface{}{"3", []interface{}{"a", "b"}},
+		[]interface{}{"0", []interface{}{"c"}},
+	}
+	calls := 0
+	sender := sendFunc(func(req Request, cb Callback, n uint64) {
+		res := replies[calls]
+		calls++
+		cb(res, nil, n)
+	})
+	it := newIterator(context.Background(), sender, "SCAN", "", ScanOpts{})
+
+	batch, ok := it.Next()
+	if !ok || len(batch) != 2 || batch[0] != "a" || batch[1] != "b" {
+		t.Fatalf("first batch = %v, %v", batch, ok)
+	}
+	batch, ok = it.Next()
+	if !ok || len(batch) != 1 || batch[0] != "c" {
+		t.Fatalf("second batch = %v, %v", batch, ok)
+	}
+	if _, ok = it.Next(); ok {
+		t.Fatalf("iterator should be exhausted once the cursor returns to 0")
+	}
+	if it.Err() != nil {
+		t.Fatalf("unexpected error after clean exhaustion: %v", it.Err())
+	}
+	if calls != 2 {
+		t.Fatalf("expected exactly 2 SCAN calls, got %d", calls)
+	}
+}
+
+func TestIteratorSurfacesErrors(t *testing.T) {
+	sender := sendFunc(func(req Request, cb Callback, n uint64) {
+		cb(nil, &Error{Code: ErrIO, Msg: "boom"}, n)
+	})
+	it := newIterator(context.Background(), sender, "SCAN", "", ScanOpts{})
+
+	if _, ok := it.Next(); ok {
+		t.Fatalf("expected Next to report failure")
+	}
+	if it.Err() == nil {
+		t.Fatalf("expected Err() to be set after a failed Next")
+	}
+}
+
+func TestIteratorHScanAddressesKey(t *testing.T) {
+	var gotArgs []interface{}
+	sender := sendFunc(func(req Request, cb Callback, n uint64) {
+		gotArgs = req.Args
+		cb([]interface{}{"0", []interface{}{}}, nil, n)
+	})
+	it := newIterator(context.Background(), sender, "HSCAN", "myhash", ScanOpts{Match: "f*", Count: 50})
+	if _, ok := it.Next(); !ok {
+		t.Fatalf("unexpected failure: %v", it.Err())
+	}
+	want := []interface{}{"myhash", "0", "MATCH", "f*", "COUNT", 50}
+	if len(gotArgs) != len(want) {
+		t.Fatalf("HSCAN args = %v, want %v", gotArgs, want)
+	}
+	for i := range want {
+		if gotArgs[i] != want[i] {
+			t.Fatalf("HSCAN args = %v, want %v", gotArgs, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package redisconnotel turns redisconn.Hooks into OpenTelemetry spans and
+// Prometheus metrics, so operators can wire redisconn into existing
+// observability stacks without patching the core package.
+package redisconnotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/joomcode/redispipe/redisconn"
+)
+
+// Hooks is a redisconn.Hooks implementation that records an OpenTelemetry
+// span per request/pipeline and updates the redis_commands_total,
+// redis_command_duration_seconds and redis_reconnects_total metrics.
+type Hooks struct {
+	Tracer trace.Tracer
+
+	commandsTotal   *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+	reconnectsTotal prometheus.Counter
+}
+
+// New returns Hooks backed by an otel.Tracer obtained under tracerName. If
+// tracerName is empty, the package path is used.
+//
+// If reg is non-nil, Hooks' Prometheus collectors are registered with it;
+// pass nil to skip registration (eg when the caller wants to register the
+// Collectors() slice itself, perhaps with different names, or not collect
+// metrics at all). Hooks never registers against the global default
+// registerer on its own, since generic names like redis_commands_total can
+// already be taken by another client's integration.
+func New(tracerName string, reg prometheus.Registerer) *Hooks {
+	if tracerName == "" {
+		tracerName = "github.com/joomcode/redispipe/redisconnotel"
+	}
+	h := &Hooks{
+		Tracer: otel.Tracer(tracerName),
+		commandsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "redis_commands_total",
+			Help: "Total number of Redis commands sent, labeled by command and outcome.",
+		}, []string{"cmd", "outcome"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "redis_command_duration_seconds",
+			Help: "Latency of Redis commands, labeled by command.",
+		}, []string{"cmd"}),
+		reconnectsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "redis_reconnects_total",
+			Help: "Total number of dial attempts made by redisconn connections.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(h.commandsTotal, h.commandDuration, h.reconnectsTotal)
+	}
+	return h
+}
+
+// Collectors returns Hooks' Prometheus collectors, for callers that want to
+// register them themselves instead of passing a Registerer to New.
+func (h *Hooks) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{h.commandsTotal, h.commandDuration, h.reconnectsTotal}
+}
+
+type spanState struct {
+	cmd   string
+	start time.Time
+}
+
+type spanStateKey struct{}
+
+// BeforeProcess implements redisconn.Hooks.
+func (h *Hooks) BeforeProcess(ctx context.Context, req redisconn.Request) context.Context {
+	ctx, _ = h.Tracer.Start(ctx, "redis."+req.Cmd)
+	return context.WithValue(ctx, spanStateKey{}, &spanState{cmd: req.Cmd, start: time.Now()})
+}
+
+// AfterProcess implements redisconn.Hooks.
+func (h *Hooks) AfterProcess(ctx context.Context, req redisconn.Request, res interface{}, err error) {
+	h.finish(ctx, err)
+}
+
+// BeforeProcessPipeline implements redisconn.Hooks.
+func (h *Hooks) BeforeProcessPipeline(ctx context.Context, requests []redisconn.Request) context.Context {
+	ctx, span := h.Tracer.Start(ctx, "redis.pipeline")
+	span.SetAttributes(attribute.Int("redis.pipeline.size", len(requests)))
+	return context.WithValue(ctx, spanStateKey{}, &spanState{cmd: "PIPELINE", start: time.Now()})
+}
+
+// AfterProcessPipeline implements redisconn.Hooks.
+func (h *Hooks) AfterProcessPipeline(ctx context.Context, requests []redisconn.Request, errs []error) {
+	var firstErr error
+	for _, e := range errs {
+		if e != nil {
+			firstErr = e
+			break
+		}
+	}
+	h.finish(ctx, firstErr)
+}
+
+// DialHook implements redisconn.Hooks.
+func (h *Hooks) DialHook(ctx context.Context, addr string, err error) {
+	h.reconnectsTotal.Inc()
+	_, span := h.Tracer.Start(ctx, "redis.dial")
+	span.SetAttributes(attribute.String("redis.addr", addr))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (h *Hooks) finish(ctx context.Context, err error) {
+	state, _ := ctx.Value(spanStateKey{}).(*spanState)
+	cmd, outcome := "unknown", "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	if state != nil {
+		cmd = state.cmd
+		h.commandDuration.WithLabelValues(cmd).Observe(time.Since(state.start).Seconds())
+	}
+	h.commandsTotal.WithLabelValues(cmd, outcome).Inc()
+
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,233 @@
+// Package redissentinel wraps redisconn.Connection with Sentinel-based
+// failover: it discovers the current master for a named set via Redis
+// Sentinel, subscribes to +switch-master notifications, and atomically
+// swaps the underlying connection when the master changes, so callers using
+// Sentinel.Send/SendBatch never have to notice a failover.
+package redissentinel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joomcode/redispipe/rediswrap"
+	"github.com/joomcode/redispipe/redisconn"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+// Opts configures a Sentinel.
+type Opts struct {
+	// ConnOpts are passed to the master Connection.
+	ConnOpts redisconn.Opts
+	// SentinelOpts are passed to the connections used to talk to the
+	// Sentinels themselves (SENTINEL commands and +switch-master).
+	SentinelOpts redisconn.Opts
+	// RoleCheck, if true, issues ROLE once each time a Connection to a
+	// newly discovered master address is opened, and rejects a server that
+	// reports "slave", guarding against split-brain writes to a stale
+	// master. The Connection's own automatic reconnects to the same address
+	// are not re-checked.
+	RoleCheck bool
+	// PollInterval is how often SENTINEL get-master-addr-by-name is
+	// polled as a fallback to the +switch-master subscription.
+	// If zero, 5 seconds is used.
+	PollInterval time.Duration
+}
+
+// Sentinel wraps a redisconn.Connection to the current master of masterName,
+// as reported by a set of Sentinels, transparently reconnecting to the new
+// master on failover.
+type Sentinel struct {
+	ctx           context.Context
+	cancel        context.CancelFunc
+	sentinelAddrs []string
+	masterName    string
+	opts          Opts
+
+	mutex      sync.Mutex
+	conn       atomic.Value // *redisconn.Connection
+	masterAddr string
+	subs       []*redisconn.PubSub
+}
+
+// Connect discovers the current master of masterName among sentinelAddrs,
+// connects to it, and starts watching for failovers.
+func Connect(ctx context.Context, sentinelAddrs []string, masterName string, opts Opts) (*Sentinel, error) {
+	if ctx == nil {
+		return nil, &redisconn.Error{Code: redisconn.ErrContextIsNil, Msg: "Context should not be nil"}
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	s := &Sentinel{sentinelAddrs: sentinelAddrs, masterName: masterName, opts: opts}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	addr, err := s.discoverMaster()
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	if err = s.swapMaster(addr); err != nil {
+		s.cancel()
+		return nil, err
+	}
+
+	s.subscribeSentinels()
+	go s.poller()
+	return s, nil
+}
+
+// Send implements the same contract as Connection.Send against the current
+// master.
+func (s *Sentinel) Send(req redisconn.Request, cb redisconn.Callback, n uint64) {
+	s.current().Send(req, cb, n)
+}
+
+// SendBatch implements the same contract as Connection.SendBatch against the
+// current master.
+func (s *Sentinel) SendBatch(requests []redisconn.Request, cb redisconn.Callback, start uint64) {
+	s.current().SendBatch(requests, cb, start)
+}
+
+// MasterAddr returns the address of the master this Sentinel currently
+// points at.
+func (s *Sentinel) MasterAddr() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.masterAddr
+}
+
+// Close stops watching for failovers and closes the master connection.
+func (s *Sentinel) Close() {
+	s.cancel()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if conn, ok := s.conn.Load().(*redisconn.Connection); ok && conn != nil {
+		conn.Close()
+	}
+	for _, ps := range s.subs {
+		ps.Close()
+	}
+}
+
+func (s *Sentinel) current() *redisconn.Connection {
+	return s.conn.Load().(*redisconn.Connection)
+}
+
+func (s *Sentinel) discoverMaster() (string, error) {
+	var lastErr error
+	for _, addr := range s.sentinelAddrs {
+		conn, err := redisconn.Connect(s.ctx, addr, s.opts.SentinelOpts)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res := rediswrap.Sync{conn}.Send(redisconn.Request{
+			Cmd:  "SENTINEL",
+			Args: []interface{}{"get-master-addr-by-name", s.masterName},
+		})
+		conn.Close()
+		if err := res.AnyError(); err != nil {
+			lastErr = err
+			continue
+		}
+		parts, ok := res.Value().([]interface{})
+		if !ok || len(parts) != 2 {
+			lastErr = &redisconn.Error{Code: redisconn.ErrResponse, Msg: "unexpected SENTINEL get-master-addr-by-name reply"}
+			continue
+		}
+		host, _ := parts[0].(string)
+		port, _ := parts[1].(string)
+		if host == "" || port == "" {
+			continue
+		}
+		return host + ":" + port, nil
+	}
+	return "", lastErr
+}
+
+func (s *Sentinel) swapMaster(addr string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if addr == s.masterAddr {
+		return nil
+	}
+	newConn, err := redisconn.Connect(s.ctx, addr, s.opts.ConnOpts)
+	if err != nil {
+		return err
+	}
+	if s.opts.RoleCheck {
+		if err = checkRole(newConn); err != nil {
+			newConn.Close()
+			return err
+		}
+	}
+	old, _ := s.conn.Load().(*redisconn.Connection)
+	s.conn.Store(newConn)
+	s.masterAddr = addr
+	if old != nil {
+		go func(old *redisconn.Connection) {
+			time.Sleep(s.opts.PollInterval)
+			old.Close()
+		}(old)
+	}
+	return nil
+}
+
+func checkRole(conn *redisconn.Connection) error {
+	res := rediswrap.Sync{conn}.Send(redisconn.Request{Cmd: "ROLE"})
+	if err := res.AnyError(); err != nil {
+		return err
+	}
+	parts, ok := res.Value().([]interface{})
+	if !ok || len(parts) == 0 {
+		return &redisconn.Error{Code: redisconn.ErrResponse, Msg: "unexpected ROLE reply"}
+	}
+	if role, _ := parts[0].(string); role == "slave" {
+		return &redisconn.Error{Code: redisconn.ErrResponse, Msg: fmt.Sprintf("%s reports role=slave, refusing to use as master", conn.Addr())}
+	}
+	return nil
+}
+
+func (s *Sentinel) subscribeSentinels() {
+	for _, addr := range s.sentinelAddrs {
+		ps, err := redisconn.NewPubSub(s.ctx, addr, s.onSwitchMaster, redisconn.PubSubOpts{
+			Password:  s.opts.SentinelOpts.Password,
+			Username:  s.opts.SentinelOpts.Username,
+			TLSConfig: s.opts.SentinelOpts.TLSConfig,
+		})
+		if err != nil {
+			continue
+		}
+		ps.Subscribe("+switch-master")
+		s.subs = append(s.subs, ps)
+	}
+}
+
+func (s *Sentinel) onSwitchMaster(msg redisconn.Message) {
+	// Payload is "<master-name> <old-ip> <old-port> <new-ip> <new-port>".
+	fields := strings.Fields(msg.Payload)
+	if len(fields) != 5 || fields[0] != s.masterName {
+		return
+	}
+	s.swapMaster(fields[3] + ":" + fields[4])
+}
+
+func (s *Sentinel) poller() {
+	t := time.NewTicker(s.opts.PollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+		if addr, err := s.discoverMaster(); err == nil {
+			s.swapMaster(addr)
+		}
+	}
+}